@@ -14,13 +14,10 @@ or implied.
 package main
 
 import (
-	"bufio"
-	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
 	"log"
-	"net"
 	"net/http"
 	"os"
 	"strconv"
@@ -28,65 +25,160 @@ import (
 	"sync"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
+	"github.com/gve-sw/gve_devnet_cp8821_battery_check/pkg/creds"
+	"github.com/gve-sw/gve_devnet_cp8821_battery_check/pkg/scan"
+	"github.com/gve-sw/gve_devnet_cp8821_battery_check/pkg/source"
 )
 
 var inputfile string
+var cidrBlock string
+var hostsList string
+var resolveTTL time.Duration
+var cucmHost string
+var cucmUser string
+var cucmPass string
+
 var chkTemp float64
 var timeout int
 var vlog bool
-var good int
-var bad int
-var unreachable int
-var hightemp int
 
-const workers = 10
+var serveAddr string
+var scanInterval time.Duration
 
-type BatteryInfo struct {
-	ip     string
-	health string
-	temp   string
-}
+var maxConcurrent int
+var retries int
+var retryBackoff time.Duration
+var scraperName string
+var retryTimeout time.Duration
+
+var httpUser string
+var httpPass string
+var credsFile string
+var loadedCreds *creds.Store
 
 func main() {
-	// Generate timestamp
-	currentTime := time.Now()
-	timestamp := string(currentTime.Format("20060102-150405"))
+	// A subcommand, not a flag - doesn't fit the flat flag.Parse() below.
+	if len(os.Args) > 1 && os.Args[1] == "encrypt-creds" {
+		runEncryptCreds(os.Args[2:])
+		return
+	}
 
 	// parse command line arguments
-	flag.StringVar(&inputfile, "infile", "", "Text list of IP addresses to check (required)")
+	flag.StringVar(&inputfile, "infile", "", "Text list of IP addresses to check")
+	flag.StringVar(&cidrBlock, "cidr", "", "Scan every host in this CIDR block, e.g. 10.20.0.0/24")
+	flag.StringVar(&hostsList, "hosts", "", "Comma-separated list of DNS hostnames to scan")
+	flag.DurationVar(&resolveTTL, "resolve-ttl", 5*time.Minute, "How long to cache DNS results for -hosts before re-resolving")
+	flag.StringVar(&cucmHost, "cucm", "", "Cisco CUCM hostname/IP to pull registered 8821 devices from via AXL")
+	flag.StringVar(&cucmUser, "cucm-user", "", "AXL API username for -cucm")
+	flag.StringVar(&cucmPass, "cucm-pass", "", "AXL API password for -cucm")
 	flag.Float64Var(&chkTemp, "temp", 50, "High temperature threshold in C (default 50)")
 	flag.IntVar(&timeout, "timeout", 10, "Time to wait for response from remote IP Phone in seconds (default 10)")
 	flag.BoolVar(&vlog, "v", false, "Enable verbose logging")
+	flag.StringVar(&serveAddr, "serve", "", "Run as a daemon and expose Prometheus metrics on this address (e.g. :9101) instead of writing a CSV report once")
+	flag.DurationVar(&scanInterval, "interval", 5*time.Minute, "How often to rescan the input list when running with -serve")
+	flag.IntVar(&maxConcurrent, "max-concurrent", 10, "Number of phones to check concurrently")
+	flag.IntVar(&retries, "retries", 2, "Number of times to retry a phone after a transient failure (timeout, connection reset, TLS handshake failure)")
+	flag.DurationVar(&retryBackoff, "retry-backoff", 2*time.Second, "Base delay between retries, doubled (with jitter) on each attempt")
+	flag.DurationVar(&retryTimeout, "retry-timeout", 60*time.Second, "Total time budget for retrying a single unreachable phone, so it can't stall the whole scan")
+	flag.StringVar(&scraperName, "scraper", "auto", "Which battery page scraper to use: auto, cp8821, cp8821ex, or generic")
+	flag.StringVar(&httpUser, "user", "", "HTTP Basic/Digest username, for phones whose web UI requires a login")
+	flag.StringVar(&httpPass, "pass", "", "HTTP Basic/Digest password")
+	flag.StringVar(&credsFile, "creds", "", "Encrypted cidr,username,password credentials file (see the 'encrypt-creds' subcommand); you will be prompted for its passphrase")
 
 	flag.Usage = func() {
 		fmt.Println("Usage:")
 		flag.PrintDefaults()
 	}
-	// Check that input file was provided - else print usage info
+	// Check that an input source was provided - else print usage info
 	flag.Parse()
-	if inputfile == "" {
-		fmt.Println("Please provide an input file!")
+	if inputfile == "" && cidrBlock == "" && hostsList == "" && cucmHost == "" {
+		fmt.Println("Please provide an input source: one of -infile, -cidr, -hosts, or -cucm!")
 		flag.Usage()
 		os.Exit(1)
 	}
 
-	// Open input file
-	infile, err := os.Open(inputfile)
+	if credsFile != "" {
+		passphrase, err := creds.ReadPassphrase("Credentials passphrase: ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		loadedCreds, err = creds.Load(credsFile, passphrase)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if serveAddr != "" {
+		if scanInterval <= 0 {
+			fmt.Println("-interval must be positive")
+			os.Exit(1)
+		}
+		runDaemon()
+		return
+	}
+	runOnce()
+}
+
+// runEncryptCreds implements the "encrypt-creds" subcommand: it reads a
+// plaintext "cidr,username,password" CSV and writes it back out AES-CFB
+// encrypted, so the result can be committed to git without exposing phone
+// admin credentials.
+func runEncryptCreds(args []string) {
+	fs := flag.NewFlagSet("encrypt-creds", flag.ExitOnError)
+	var in, out string
+	fs.StringVar(&in, "in", "", "Plaintext CSV of cidr,username,password rows (required)")
+	fs.StringVar(&out, "out", "", "Path to write the encrypted credentials file (required)")
+	fs.Parse(args)
+	if in == "" || out == "" {
+		fmt.Println("Usage: cp8821-check encrypt-creds -in creds.csv -out creds.enc")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	passphrase, err := creds.ReadPassphrase("New credentials passphrase: ")
 	if err != nil {
 		log.Fatal(err)
 	}
-	defer infile.Close()
-
-	// Count addresses in file:
-	fmt.Println("Validating input file...")
-	validAddr, invalidAddr := countLines(infile)
-	fmt.Println("Found " + strconv.Itoa(validAddr) + " addresses to check")
-	if invalidAddr >= 1 {
-		fmt.Println(strconv.Itoa(invalidAddr) + " adddreses are invalid & will not be checked.")
+	if err := creds.Encrypt(in, out, passphrase); err != nil {
+		log.Fatal(err)
 	}
-	// Reset to first line in file after reading during line count
-	infile.Seek(0, io.SeekStart)
+	fmt.Println("Wrote encrypted credentials to " + out)
+}
+
+// buildSource picks the address Source to scan from based on which input
+// flags were provided. Only one of -infile, -cidr, -hosts, -cucm is used at a
+// time, checked in the order -cucm, -hosts, -cidr, -infile.
+func buildSource() (source.Source, error) {
+	switch {
+	case cucmHost != "":
+		return source.CUCMSource{Host: cucmHost, User: cucmUser, Pass: cucmPass}, nil
+	case hostsList != "":
+		return &source.HostnameSource{Hostnames: strings.Split(hostsList, ","), TTL: resolveTTL, Verbose: vlog}, nil
+	case cidrBlock != "":
+		return source.CIDRSource{CIDR: cidrBlock}, nil
+	case inputfile != "":
+		return source.FileSource{Path: inputfile, Verbose: vlog}, nil
+	default:
+		return nil, fmt.Errorf("no input source provided")
+	}
+}
+
+// runOnce performs a single scan of the input source and writes the ALL/BAD CSV
+// reports. This is the tool's original, default behavior.
+func runOnce() {
+	// Generate timestamp
+	currentTime := time.Now()
+	timestamp := currentTime.Format("20060102-150405")
+
+	src, err := buildSource()
+	if err != nil {
+		log.Fatal(err)
+	}
+	addresses, err := src.Addresses()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println("Found " + strconv.Itoa(len(addresses)) + " addresses to check")
 
 	// Create output files
 	allResults, err := os.Create(timestamp + "-ALL.csv")
@@ -103,183 +195,199 @@ func main() {
 	defer allResults.Close()
 	defer badResults.Close()
 
-	// Create channels for jobs queue & worker results
-	jobs := make(chan string, validAddr)
-	results := make(chan BatteryInfo, workers)
-
-	// Start workers
-	var wg sync.WaitGroup
-	for w := 1; w <= workers; w++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			getWebPage(w, jobs, results)
-		}()
-	}
-
-	// Read each line of input file, and send to getWebPage
 	fmt.Println("Working...")
-	scanner := bufio.NewScanner(infile)
-	for scanner.Scan() {
-
-		// Strip any whitespace from IP
-		ip := strings.TrimSpace(scanner.Text())
-		// Ensure IP is valid
-		if net.ParseIP(strings.Split(ip, ":")[0]) == nil {
-			if vlog {
-				fmt.Println("Invalid address: ", ip)
-			}
-			continue
-		}
-		// Load into jobs queue
-		jobs <- ip
-	}
-	if vlog {
-		fmt.Println("All jobs loaded into queue!")
-	}
-	// Close jobs channel after loading everything in
-	close(jobs)
+	results, summary := scan.Run(scanConfig(), addresses)
 
-	for a := 1; a <= validAddr; a++ {
-		battery_status := <-results
+	for _, battery_status := range results {
 		if vlog {
 			fmt.Println("Got Result, writing to CSV: ", battery_status)
 		}
 		// Write line to file
-		result_info := fmt.Sprintf("%s,%s,%s\n", battery_status.ip, battery_status.health, battery_status.temp)
-		_, err := allResults.WriteString(result_info)
-		if err != nil {
+		result_info := fmt.Sprintf("%s,%s,%s\n", battery_status.IP, battery_status.Health, battery_status.Temp)
+		if _, err := allResults.WriteString(result_info); err != nil {
 			log.Fatal(err)
 		}
-		// Increment counters for result summary
-		if battery_status.health == "Good" {
-			good += 1
-		} else {
-			bad += 1
-		}
 		// If battery status is anything except "Good",
 		// it gets added to the "bad" list
-		if !strings.Contains(battery_status.health, "Good") {
-			_, err = badResults.WriteString(result_info)
-			if err != nil {
+		if !strings.Contains(battery_status.Health, "Good") {
+			if _, err := badResults.WriteString(result_info); err != nil {
 				log.Fatal(err)
 			}
 		}
-		// If we got a temp from the IP Phone,
-		// check against temp threshold
-		if battery_status.health != "Unknown" {
-			// Split temp string & pull digits out
-			temp := strings.Split(battery_status.temp, " degrees Celsius")[0]
-			// Convert to Float & check against provided threshold
-			if a, err := strconv.ParseFloat(temp, 64); a > chkTemp {
-				if err != nil {
-					continue
-				}
-				hightemp += 1
-			}
-		}
-		// Print worker status
-		if vlog {
-			fmt.Println("Jobs remaining:   " + strconv.Itoa(len(jobs)) + " of " + strconv.Itoa(cap(jobs)))
-			fmt.Println("Results in queue:", strconv.Itoa(len(results)))
-		}
-
 	}
-	// Wait for workers to finish
 
-	wg.Wait()
 	// Print results summary
 	fmt.Println("Done!")
 	fmt.Println("Summary: ")
-	fmt.Println(" - Good: " + strconv.Itoa(good))
-	fmt.Println(" - Bad: " + strconv.Itoa(bad))
+	fmt.Println(" - Good: " + strconv.Itoa(summary.Good))
+	fmt.Println(" - Bad: " + strconv.Itoa(summary.Bad))
 	fmt.Println("Breakdown of 'bad' status:")
-	fmt.Println(" - Health: " + strconv.Itoa(bad-(hightemp+unreachable)))
-	fmt.Println(" - High Temp: " + strconv.Itoa(hightemp))
-	fmt.Println(" - Unreachable/Unknown: " + strconv.Itoa(unreachable))
-
+	fmt.Println(" - Health: " + strconv.Itoa(summary.Bad-(summary.HighTemp+summary.Unreachable)))
+	fmt.Println(" - High Temp: " + strconv.Itoa(summary.HighTemp))
+	fmt.Println(" - Unreachable/Unknown: " + strconv.Itoa(summary.Unreachable))
+	fmt.Println(" - Transient failures recovered on retry: " + strconv.Itoa(summary.RecoveredOnRetry))
 }
 
-// getWebPage queries a remote web page
-func getWebPage(id int, addresses <-chan string, results chan<- BatteryInfo) {
-	for address := range addresses {
+// runDaemon starts an HTTP server that rescans the input list on a timer and
+// exposes the latest results as Prometheus metrics, a health check, and JSON -
+// so NOC teams can wire fleet battery health into their existing alerting
+// instead of running a cron job that diffs CSVs.
+func runDaemon() {
+	src, err := buildSource()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	store := newResultStore()
+	cfg := scanConfig()
+
+	runScan := func() {
 		if vlog {
-			fmt.Println("Worker ID:" + strconv.Itoa(id) + " - Working on : " + address)
-		}
-		// HTTP Client Config.
-		// Disable Certificate check & Set timeout
-		tlsCfg := &http.Transport{
-			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-		}
-		client := &http.Client{
-			Timeout:   time.Duration(timeout) * time.Second,
-			Transport: tlsCfg,
-		}
-		// Send HTTP GET
-		resp, err := client.Get("https://" + address)
-		// Fallback to HTTP if client does not support TLS
-		if err != nil && strings.HasSuffix(err.Error(), "server gave HTTP response to HTTPS client") {
-			if vlog {
-				fmt.Println("Worker ID:" + strconv.Itoa(id) + " | Client: " + address + " - Fallback to HTTP")
-			}
-			resp, err = client.Get("http://" + address)
+			fmt.Println("Starting scan...")
 		}
+		// Re-resolve the source on every scan - CIDR blocks are static, but
+		// hostnames and the CUCM inventory can change between ticks.
+		addresses, err := src.Addresses()
 		if err != nil {
-			if vlog {
-				fmt.Println("Worker ID:" + strconv.Itoa(id) + " - Cannot connect to: " + address)
-				fmt.Println(err)
-			}
-			unreachable += 1
-			results <- BatteryInfo{ip: address, health: "Unknown", temp: ""}
-			continue
+			log.Println("Could not build address list: ", err)
+			return
 		}
+		fmt.Println("Found " + strconv.Itoa(len(addresses)) + " addresses to check")
+		results, summary := scan.Run(cfg, addresses)
+		store.update(results, summary)
 		if vlog {
-			fmt.Println("Worker ID:" + strconv.Itoa(id) + " Got response from " + address)
+			fmt.Println("Scan complete.")
 		}
-		// Parse HTML response
-		doc, err := goquery.NewDocumentFromReader(resp.Body)
-		if err != nil {
-			log.Fatal(err)
+	}
+
+	// Run an initial scan before serving so /metrics and /results have data
+	// as soon as the daemon comes up.
+	runScan()
+
+	go func() {
+		ticker := time.NewTicker(scanInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			runScan()
 		}
+	}()
 
-		info := new(BatteryInfo)
-		info.ip = address
-		// Find table on IP Phone home page, which contains health stats / info
-		doc.Find("table").Each(func(index int, tablehtml *goquery.Selection) {
-			// Battery info is located in third table
-			if index == 2 {
-				// Locate table rows that contain battery health & temp info
-				tablehtml.Find("tr").Each(func(index int, tablerow *goquery.Selection) {
-					if strings.Contains(tablerow.Text(), "Battery health") {
-						info.health = strings.Split(tablerow.Text(), "Battery health")[1]
-					}
-					if strings.Contains(tablerow.Text(), "Battery temperature:") {
-						info.temp = strings.Split(tablerow.Text(), "Battery temperature: ")[1]
-					}
-				})
-			}
-		})
-		resp.Body.Close()
-		results <- *info
+	http.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	http.HandleFunc("/results", func(w http.ResponseWriter, r *http.Request) {
+		store.writeJSON(w)
+	})
+	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		store.writeMetrics(w)
+	})
+
+	fmt.Println("Serving metrics on " + serveAddr)
+	log.Fatal(http.ListenAndServe(serveAddr, nil))
+}
+
+// scanConfig builds a scan.Config from the flags parsed in main.
+func scanConfig() scan.Config {
+	return scan.Config{
+		Workers:      maxConcurrent,
+		Timeout:      time.Duration(timeout) * time.Second,
+		TempThresh:   chkTemp,
+		Verbose:      vlog,
+		Retries:      retries,
+		RetryBackoff: retryBackoff,
+		RetryTimeout: retryTimeout,
+		Scraper:      scraperName,
+		Username:     httpUser,
+		Password:     httpPass,
+		Creds:        loadedCreds,
 	}
 }
 
-// countLines takes in a file & counts the number of lines which contain a valid IPv4 address
-func countLines(input *os.File) (int, int) {
-	// Read file
-	scanner := bufio.NewScanner(input)
-	valid := 0
-	invalid := 0
-
-	// Count lines
-	for scanner.Scan() {
-		ip := strings.TrimSpace(scanner.Text())
-		// Ensure IP is valid
-		if net.ParseIP(strings.Split(ip, ":")[0]) != nil {
-			valid++
-		} else {
-			invalid++
+// resultStore holds the most recent scan results so the HTTP handlers always
+// serve a consistent snapshot, even while the next scan is running.
+type resultStore struct {
+	mu      sync.Mutex
+	results []scan.BatteryInfo
+	summary scan.Summary
+	scanned time.Time
+}
+
+func newResultStore() *resultStore {
+	return &resultStore{}
+}
+
+func (s *resultStore) update(results []scan.BatteryInfo, summary scan.Summary) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = results
+	s.summary = summary
+	s.scanned = time.Now()
+}
+
+func (s *resultStore) snapshot() ([]scan.BatteryInfo, scan.Summary, time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.results, s.summary, s.scanned
+}
+
+// writeJSON writes the last scan's results as JSON for the /results endpoint.
+func (s *resultStore) writeJSON(w http.ResponseWriter) {
+	results, summary, scanned := s.snapshot()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		ScannedAt time.Time          `json:"scanned_at"`
+		Summary   scan.Summary       `json:"summary"`
+		Results   []scan.BatteryInfo `json:"results"`
+	}{scanned, summary, results})
+}
+
+// writeMetrics renders the last scan's results in Prometheus text exposition
+// format for the /metrics endpoint.
+func (s *resultStore) writeMetrics(w http.ResponseWriter) {
+	results, summary, _ := s.snapshot()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+
+	fmt.Fprintln(w, "# HELP cp8821_battery_temp_celsius Battery temperature reported on the last scan, in Celsius.")
+	fmt.Fprintln(w, "# TYPE cp8821_battery_temp_celsius gauge")
+	for _, r := range results {
+		temp := strings.Split(r.Temp, " degrees Celsius")[0]
+		if t, err := strconv.ParseFloat(temp, 64); err == nil {
+			fmt.Fprintf(w, "cp8821_battery_temp_celsius{ip=%q} %g\n", r.IP, t)
 		}
 	}
-	return valid, invalid
+
+	fmt.Fprintln(w, "# HELP cp8821_battery_health Battery health reported on the last scan.")
+	fmt.Fprintln(w, "# TYPE cp8821_battery_health gauge")
+	for _, r := range results {
+		if r.Health == "" {
+			continue
+		}
+		fmt.Fprintf(w, "cp8821_battery_health{ip=%q,health=%q} 1\n", r.IP, r.Health)
+	}
+
+	fmt.Fprintln(w, "# HELP cp8821_unreachable Whether the phone could not be reached on the last scan.")
+	fmt.Fprintln(w, "# TYPE cp8821_unreachable gauge")
+	for _, r := range results {
+		v := 0
+		if r.Health == "Unknown" {
+			v = 1
+		}
+		fmt.Fprintf(w, "cp8821_unreachable{ip=%q} %d\n", r.IP, v)
+	}
+
+	fmt.Fprintln(w, "# HELP cp8821_scan_good Phones with Good battery health on the last scan.")
+	fmt.Fprintln(w, "# TYPE cp8821_scan_good gauge")
+	fmt.Fprintf(w, "cp8821_scan_good %d\n", summary.Good)
+
+	fmt.Fprintln(w, "# HELP cp8821_scan_bad Phones with non-Good battery health on the last scan.")
+	fmt.Fprintln(w, "# TYPE cp8821_scan_bad gauge")
+	fmt.Fprintf(w, "cp8821_scan_bad %d\n", summary.Bad)
+
+	fmt.Fprintln(w, "# HELP cp8821_scan_hightemp Phones over the configured temperature threshold on the last scan.")
+	fmt.Fprintln(w, "# TYPE cp8821_scan_hightemp gauge")
+	fmt.Fprintf(w, "cp8821_scan_hightemp %d\n", summary.HighTemp)
+
+	fmt.Fprintln(w, "# HELP cp8821_scan_recovered_on_retry Transient failures that succeeded on a retry during the last scan.")
+	fmt.Fprintln(w, "# TYPE cp8821_scan_recovered_on_retry gauge")
+	fmt.Fprintf(w, "cp8821_scan_recovered_on_retry %d\n", summary.RecoveredOnRetry)
 }
@@ -0,0 +1,222 @@
+/*
+Copyright (c) 2022 Cisco and/or its affiliates.
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.1 (the "License"). You may obtain a copy of the
+License at
+               https://developer.cisco.com/docs/licenses
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+// Package creds loads the per-CIDR phone web UI credentials used when a
+// deployment locks the admin page behind a login. Credentials are kept on
+// disk AES-CFB encrypted, with the key derived from an operator-supplied
+// passphrase via PBKDF2, so the file can safely live in git.
+package creds
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/csv"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/term"
+)
+
+const (
+	saltSize    = 16
+	keySize     = 32 // size of each of the AES-256 and HMAC-SHA256 keys, split from one PBKDF2 output below
+	macSize     = 32
+	pbkdf2Iters = 100000
+)
+
+// Credential is a username/password scoped to the phones in a CIDR block.
+type Credential struct {
+	CIDR     string
+	Username string
+	Password string
+
+	network *net.IPNet
+}
+
+// Store holds every Credential loaded from a file and resolves one by
+// address.
+type Store struct {
+	creds []Credential
+}
+
+// Load decrypts the credentials file at path with passphrase and parses its
+// "cidr,username,password" rows.
+func Load(path string, passphrase []byte) (*Store, error) {
+	plaintext, err := decryptFile(path, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	r := csv.NewReader(bytes.NewReader(plaintext))
+	r.FieldsPerRecord = 3
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("parsing credentials: %w", err)
+	}
+
+	store := &Store{}
+	for _, rec := range records {
+		cidr := strings.TrimSpace(rec[0])
+		network, err := toNetwork(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR %q in credentials file: %w", cidr, err)
+		}
+		store.creds = append(store.creds, Credential{
+			CIDR:     cidr,
+			Username: strings.TrimSpace(rec[1]),
+			Password: strings.TrimSpace(rec[2]),
+			network:  network,
+		})
+	}
+	return store, nil
+}
+
+// toNetwork parses s as a CIDR block, treating a bare IP as a /32 (or /128).
+func toNetwork(s string) (*net.IPNet, error) {
+	if !strings.Contains(s, "/") {
+		if ip := net.ParseIP(s); ip != nil {
+			if ip.To4() != nil {
+				s += "/32"
+			} else {
+				s += "/128"
+			}
+		}
+	}
+	_, network, err := net.ParseCIDR(s)
+	return network, err
+}
+
+// Lookup returns the username/password to use for address, and whether a
+// matching CIDR entry was found. The first matching entry wins.
+func (s *Store) Lookup(address string) (string, string, bool) {
+	if s == nil {
+		return "", "", false
+	}
+	ip := net.ParseIP(strings.Split(address, ":")[0])
+	if ip == nil {
+		return "", "", false
+	}
+	for _, c := range s.creds {
+		if c.network != nil && c.network.Contains(ip) {
+			return c.Username, c.Password, true
+		}
+	}
+	return "", "", false
+}
+
+// Encrypt reads a plaintext "cidr,username,password" CSV from plainPath and
+// writes it AES-CFB encrypted to outPath, keyed off passphrase. The
+// ciphertext is HMAC-SHA256 tagged so a wrong passphrase or a corrupted file
+// is rejected outright instead of silently decrypting to garbage.
+func Encrypt(plainPath, outPath string, passphrase []byte) error {
+	plaintext, err := os.ReadFile(plainPath)
+	if err != nil {
+		return err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return err
+	}
+	encKey, macKey := deriveKeys(passphrase, salt)
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return err
+	}
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(iv); err != nil {
+		return err
+	}
+
+	ciphertext := make([]byte, len(plaintext))
+	cipher.NewCFBEncrypter(block, iv).XORKeyStream(ciphertext, plaintext)
+
+	tag := hmac.New(sha256.New, macKey)
+	tag.Write(salt)
+	tag.Write(iv)
+	tag.Write(ciphertext)
+
+	out, err := os.Create(outPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	for _, chunk := range [][]byte{salt, iv, ciphertext, tag.Sum(nil)} {
+		if _, err := out.Write(chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decryptFile reads a file written by Encrypt and returns its plaintext,
+// rejecting it if passphrase is wrong or the file was tampered with.
+func decryptFile(path string, passphrase []byte) ([]byte, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < saltSize+aes.BlockSize+macSize {
+		return nil, fmt.Errorf("credentials file too short to be valid")
+	}
+	salt, raw := raw[:saltSize], raw[saltSize:]
+	iv, raw := raw[:aes.BlockSize], raw[aes.BlockSize:]
+	ciphertext, wantTag := raw[:len(raw)-macSize], raw[len(raw)-macSize:]
+
+	encKey, macKey := deriveKeys(passphrase, salt)
+
+	tag := hmac.New(sha256.New, macKey)
+	tag.Write(salt)
+	tag.Write(iv)
+	tag.Write(ciphertext)
+	if !hmac.Equal(tag.Sum(nil), wantTag) {
+		return nil, fmt.Errorf("wrong passphrase or corrupted credentials file")
+	}
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCFBDecrypter(block, iv).XORKeyStream(plaintext, ciphertext)
+	return plaintext, nil
+}
+
+// deriveKeys stretches passphrase+salt into a 256-bit AES key and a separate
+// 256-bit HMAC key.
+func deriveKeys(passphrase, salt []byte) (encKey, macKey []byte) {
+	stretched := pbkdf2.Key(passphrase, salt, pbkdf2Iters, keySize*2, sha256.New)
+	return stretched[:keySize], stretched[keySize:]
+}
+
+// ReadPassphrase prints prompt to stderr and reads a passphrase from stdin
+// without echoing it to the terminal.
+func ReadPassphrase(prompt string) ([]byte, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, fmt.Errorf("reading passphrase: %w", err)
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,212 @@
+package scan
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// digestChallenge is a parsed "WWW-Authenticate: Digest ..." header.
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	qop       string
+	opaque    string
+	algorithm string
+}
+
+// parseWWWAuthenticate inspects a 401 response's WWW-Authenticate header(s)
+// and reports whether Basic was offered, and the parsed Digest challenge if
+// one was.
+func parseWWWAuthenticate(resp *http.Response) (basic bool, digest *digestChallenge) {
+	for _, header := range resp.Header.Values("WWW-Authenticate") {
+		scheme := strings.SplitN(strings.TrimSpace(header), " ", 2)
+		switch strings.ToLower(scheme[0]) {
+		case "basic":
+			basic = true
+		case "digest":
+			if len(scheme) == 2 {
+				digest = parseDigestChallenge(scheme[1])
+			}
+		}
+	}
+	return basic, digest
+}
+
+func parseDigestChallenge(params string) *digestChallenge {
+	c := &digestChallenge{algorithm: "MD5"}
+	for _, part := range splitDigestParams(params) {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		value := strings.Trim(strings.TrimSpace(kv[1]), `"`)
+		switch strings.ToLower(strings.TrimSpace(kv[0])) {
+		case "realm":
+			c.realm = value
+		case "nonce":
+			c.nonce = value
+		case "qop":
+			c.qop = value
+		case "opaque":
+			c.opaque = value
+		case "algorithm":
+			c.algorithm = value
+		}
+	}
+	return c
+}
+
+// splitDigestParams splits a comma-separated list of key=value pairs,
+// ignoring commas inside quoted values.
+func splitDigestParams(s string) []string {
+	var parts []string
+	var cur strings.Builder
+	inQuotes := false
+	for _, r := range s {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+			cur.WriteRune(r)
+		case r == ',' && !inQuotes:
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		parts = append(parts, cur.String())
+	}
+	return parts
+}
+
+// buildDigestHeader computes the Authorization header for c per RFC 7616,
+// using a fresh client nonce for this request. c.algorithm selects the hash:
+// MD5 (RFC 2617, the default when a server doesn't send "algorithm") or
+// SHA-256, each with a "-sess" variant that folds the nonce/cnonce into HA1.
+func buildDigestHeader(c *digestChallenge, method, uri, username, password string) (string, error) {
+	cnonce, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+	const nc = "00000001"
+
+	hashHex, algorithm, sess := digestHash(c.algorithm)
+
+	ha1 := hashHex(username + ":" + c.realm + ":" + password)
+	if sess {
+		ha1 = hashHex(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := hashHex(method + ":" + uri)
+
+	var response string
+	if c.qop != "" {
+		response = hashHex(strings.Join([]string{ha1, c.nonce, nc, cnonce, "auth", ha2}, ":"))
+	} else {
+		response = hashHex(ha1 + ":" + c.nonce + ":" + ha2)
+	}
+
+	header := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", response="%s", algorithm=%s`,
+		username, c.realm, c.nonce, uri, response, algorithm)
+	if c.qop != "" {
+		header += fmt.Sprintf(`, qop=auth, nc=%s, cnonce="%s"`, nc, cnonce)
+	}
+	if c.opaque != "" {
+		header += fmt.Sprintf(`, opaque="%s"`, c.opaque)
+	}
+	return header, nil
+}
+
+// digestHash maps a WWW-Authenticate "algorithm" value to its hash function,
+// the algorithm name to echo back in the Authorization header, and whether
+// it's a "-sess" variant. Unrecognized base algorithms fall back to MD5,
+// keeping any "-sess" suffix (so e.g. "SHA-512-256-sess" becomes MD5-sess).
+func digestHash(algorithm string) (hashHex func(string) string, name string, sess bool) {
+	base := strings.TrimSuffix(strings.ToUpper(algorithm), "-SESS")
+	sess = strings.HasSuffix(strings.ToUpper(algorithm), "-SESS")
+	switch base {
+	case "SHA-256":
+		name = "SHA-256"
+		if sess {
+			name += "-sess"
+		}
+		return sha256Hex, name, sess
+	default:
+		name = "MD5"
+		if sess {
+			name += "-sess"
+		}
+		return md5Hex, name, sess
+	}
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// doGet issues a GET for scheme://address, optionally customized by
+// configure (e.g. to set an Authorization header).
+func doGet(client *http.Client, scheme, address string, configure func(*http.Request)) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, scheme+"://"+address, nil)
+	if err != nil {
+		return nil, err
+	}
+	if configure != nil {
+		configure(req)
+	}
+	return client.Do(req)
+}
+
+// fetchAuthenticated GETs scheme://address, trying anonymous access first,
+// then HTTP Basic, then HTTP Digest computed from the 401's WWW-Authenticate
+// challenge - stopping as soon as one succeeds or credentials run out.
+func fetchAuthenticated(client *http.Client, scheme, address, username, password string, haveCreds bool) (*http.Response, error) {
+	resp, err := doGet(client, scheme, address, nil)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || !haveCreds {
+		return resp, err
+	}
+	resp.Body.Close()
+
+	basicResp, err := doGet(client, scheme, address, func(req *http.Request) {
+		req.SetBasicAuth(username, password)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if basicResp.StatusCode != http.StatusUnauthorized {
+		return basicResp, nil
+	}
+
+	_, digest := parseWWWAuthenticate(basicResp)
+	if digest == nil || digest.nonce == "" {
+		return basicResp, nil
+	}
+	basicResp.Body.Close()
+
+	header, err := buildDigestHeader(digest, http.MethodGet, "/", username, password)
+	if err != nil {
+		return nil, err
+	}
+	return doGet(client, scheme, address, func(req *http.Request) {
+		req.Header.Set("Authorization", header)
+	})
+}
@@ -0,0 +1,159 @@
+package scan
+
+import (
+	"crypto/tls"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// result is what a worker hands back to Run for a single address.
+type result struct {
+	info      BatteryInfo
+	recovered bool // true if the phone only answered after a retry
+}
+
+// worker pulls addresses off the jobs channel and queries each one, retrying
+// transient failures per cfg before giving up.
+func worker(id int, cfg Config, addresses <-chan string, results chan<- result) {
+	for address := range addresses {
+		if cfg.Verbose {
+			fmt.Println("Worker ID:" + strconv.Itoa(id) + " - Working on : " + address)
+		}
+		results <- fetchWithRetry(id, cfg, address)
+	}
+}
+
+// fetchWithRetry queries address, retrying transient failures (timeouts,
+// connection resets, TLS handshake failures) with exponential backoff and
+// jitter up to cfg.Retries times, or until cfg.RetryTimeout's budget runs
+// out. HTTP 401/404 are treated as permanent and are not retried.
+func fetchWithRetry(id int, cfg Config, address string) result {
+	var deadline time.Time
+	if cfg.RetryTimeout > 0 {
+		deadline = time.Now().Add(cfg.RetryTimeout)
+	}
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 {
+			backoff := jitter(cfg.RetryBackoff * (1 << uint(attempt-1)))
+			if !deadline.IsZero() && time.Now().Add(backoff).After(deadline) {
+				break
+			}
+			if cfg.Verbose {
+				fmt.Printf("Worker ID:%d - Retrying %s in %s (attempt %d/%d)\n", id, address, backoff, attempt, cfg.Retries)
+			}
+			time.Sleep(backoff)
+		}
+
+		info, permanent, err := fetchOnce(id, cfg, address)
+		if err == nil {
+			return result{info: info, recovered: attempt > 0}
+		}
+		if permanent || attempt >= cfg.Retries {
+			if cfg.Verbose {
+				fmt.Println("Worker ID:" + strconv.Itoa(id) + " - Giving up on: " + address + " - " + err.Error())
+			}
+			break
+		}
+	}
+	return result{info: BatteryInfo{IP: address, Health: "Unknown"}}
+}
+
+// fetchOnce makes a single attempt at querying address. The bool return is
+// true when err represents a permanent failure (e.g. HTTP 401/404) that
+// retrying would not fix.
+func fetchOnce(id int, cfg Config, address string) (BatteryInfo, bool, error) {
+	// HTTP Client Config.
+	// Disable Certificate check & Set timeout
+	tlsCfg := &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+	}
+	client := &http.Client{
+		Timeout:   cfg.Timeout,
+		Transport: tlsCfg,
+	}
+
+	username, password, haveCreds := cfg.credentialsFor(address)
+
+	// Send HTTP GET, trying anonymous access first and falling back to
+	// Basic/Digest auth if the phone's web UI requires a login.
+	resp, err := fetchAuthenticated(client, "https", address, username, password, haveCreds)
+	// Fallback to HTTP if client does not support TLS
+	if err != nil && strings.HasSuffix(err.Error(), "server gave HTTP response to HTTPS client") {
+		if cfg.Verbose {
+			fmt.Println("Worker ID:" + strconv.Itoa(id) + " | Client: " + address + " - Fallback to HTTP")
+		}
+		resp, err = fetchAuthenticated(client, "http", address, username, password, haveCreds)
+	}
+	if err != nil {
+		if cfg.Verbose {
+			fmt.Println("Worker ID:" + strconv.Itoa(id) + " - Cannot connect to: " + address)
+			fmt.Println(err)
+		}
+		return BatteryInfo{}, !isTransient(err), err
+	}
+	defer resp.Body.Close()
+
+	// 401/404 mean the phone answered but won't ever serve this page -
+	// retrying is pointless.
+	if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusNotFound {
+		return BatteryInfo{}, true, fmt.Errorf("%s: HTTP %d", address, resp.StatusCode)
+	}
+
+	if cfg.Verbose {
+		fmt.Println("Worker ID:" + strconv.Itoa(id) + " Got response from " + address)
+	}
+	// Parse HTML response
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return BatteryInfo{}, !isTransient(err), fmt.Errorf("%s: parsing response: %w", address, err)
+	}
+
+	name, scraper := selectScraper(cfg.Scraper, doc)
+	if cfg.Verbose {
+		fmt.Println("Worker ID:" + strconv.Itoa(id) + " - Matched scraper \"" + name + "\" for " + address)
+	}
+	info, err := scraper.Parse(doc)
+	if err != nil {
+		// A markup mismatch won't fix itself on retry.
+		return BatteryInfo{}, true, fmt.Errorf("%s: %w", address, err)
+	}
+	info.IP = address
+	return info, false, nil
+}
+
+// isTransient reports whether err looks like a temporary network hiccup
+// (timeout, connection reset, TLS handshake failure) worth retrying, as
+// opposed to something that will never succeed.
+func isTransient(err error) bool {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return true
+	}
+	msg := err.Error()
+	for _, transient := range []string{"connection reset", "handshake failure", "EOF", "connection refused", "broken pipe"} {
+		if strings.Contains(msg, transient) {
+			return true
+		}
+	}
+	return false
+}
+
+// jitter returns d plus or minus up to 20%, so a fleet of workers retrying at
+// once doesn't all hammer the same phone in lockstep.
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 || int64(d)/5 == 0 {
+		return d
+	}
+	delta := time.Duration(rand.Int63n(int64(d) / 5))
+	if rand.Intn(2) == 0 {
+		return d - delta
+	}
+	return d + delta
+}
@@ -0,0 +1,132 @@
+/*
+Copyright (c) 2022 Cisco and/or its affiliates.
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.1 (the "License"). You may obtain a copy of the
+License at
+               https://developer.cisco.com/docs/licenses
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+// Package scan contains the worker pool that queries IP Phones for their
+// battery status. It is shared by both the one-shot CSV report and the
+// long-running daemon mode, so a scan is always driven the same way no
+// matter how the results end up getting used.
+package scan
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gve-sw/gve_devnet_cp8821_battery_check/pkg/creds"
+)
+
+// BatteryInfo holds the battery status reported by a single IP Phone.
+type BatteryInfo struct {
+	IP     string
+	Health string
+	Temp   string
+}
+
+// Summary tallies the results of a completed scan.
+type Summary struct {
+	Good             int
+	Bad              int
+	Unreachable      int
+	HighTemp         int
+	RecoveredOnRetry int // transient failures that succeeded on a retry
+}
+
+// Config controls how a scan is performed.
+type Config struct {
+	Workers    int           // number of concurrent workers polling phones
+	Timeout    time.Duration // per-request HTTP timeout
+	TempThresh float64       // temperature in C above which a phone counts as "high temp"
+	Verbose    bool          // enable verbose logging
+
+	Retries      int           // number of retries for transient failures (0 disables retrying)
+	RetryBackoff time.Duration // base delay between retries, doubled on each attempt and jittered
+	RetryTimeout time.Duration // total time budget for retrying a single address (0 means no budget)
+
+	Scraper string // which Scraper to parse battery info with: "auto", "cp8821", "cp8821ex", or "generic"
+
+	Username string       // fallback HTTP Basic/Digest username, used if Creds has no match
+	Password string       // fallback HTTP Basic/Digest password
+	Creds    *creds.Store // optional per-CIDR credentials, checked before Username/Password
+}
+
+// credentialsFor resolves the username/password to use for address: Creds is
+// checked first, falling back to the global Username/Password.
+func (cfg Config) credentialsFor(address string) (string, string, bool) {
+	if cfg.Creds != nil {
+		if user, pass, ok := cfg.Creds.Lookup(address); ok {
+			return user, pass, true
+		}
+	}
+	if cfg.Username != "" {
+		return cfg.Username, cfg.Password, true
+	}
+	return "", "", false
+}
+
+// Run queries every address in addresses for its battery status and returns
+// the per-phone results along with a summary of the scan. Addresses are
+// distributed across cfg.Workers workers, matching the original tool's
+// worker pool. cfg.Workers below 1 (e.g. an unvalidated -max-concurrent) is
+// clamped to 1 rather than panicking or silently scanning nothing.
+func Run(cfg Config, addresses []string) ([]BatteryInfo, Summary) {
+	if cfg.Workers < 1 {
+		cfg.Workers = 1
+	}
+
+	jobs := make(chan string, len(addresses))
+	results := make(chan result, cfg.Workers)
+
+	var wg sync.WaitGroup
+	for w := 1; w <= cfg.Workers; w++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			worker(id, cfg, jobs, results)
+		}(w)
+	}
+
+	for _, address := range addresses {
+		jobs <- address
+	}
+	close(jobs)
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	var all []BatteryInfo
+	var summary Summary
+	for r := range results {
+		all = append(all, r.info)
+		if r.recovered {
+			summary.RecoveredOnRetry++
+		}
+		if r.info.Health == "Good" {
+			summary.Good++
+		} else {
+			summary.Bad++
+		}
+		if r.info.Health == "Unknown" {
+			summary.Unreachable++
+			continue
+		}
+		temp := strings.Split(r.info.Temp, " degrees Celsius")[0]
+		if t, err := strconv.ParseFloat(temp, 64); err == nil && t > cfg.TempThresh {
+			summary.HighTemp++
+		}
+	}
+	return all, summary
+}
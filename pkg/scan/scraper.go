@@ -0,0 +1,158 @@
+package scan
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Scraper extracts battery status from an IP Phone's parsed home page. Phone
+// models, and even firmware revisions of the same model, lay the battery
+// table out differently, so each one gets its own Scraper rather than a
+// single hardcoded table index.
+type Scraper interface {
+	Parse(doc *goquery.Document) (BatteryInfo, error)
+}
+
+// scraperEntry pairs a Scraper with the fingerprint check used to pick it in
+// "auto" mode.
+type scraperEntry struct {
+	name    string
+	matches func(fingerprint string) bool
+	scraper Scraper
+}
+
+// registry is checked in order for "auto" mode; the first matching entry
+// wins. Entries are ordered most-specific first so e.g. "8821-EX" is matched
+// before the more general "8821".
+var registry = []scraperEntry{
+	{
+		name:    "cp8821ex",
+		matches: func(fp string) bool { return strings.Contains(fp, "8821-EX") || strings.Contains(fp, "8821EX") },
+		scraper: cp8821exScraper{},
+	},
+	{
+		name:    "cp8821",
+		matches: func(fp string) bool { return strings.Contains(fp, "8821") },
+		scraper: cp8821Scraper{},
+	},
+}
+
+// fingerprint returns the text used to identify a phone's model: its home
+// page title, which on every known 8821/8821-EX/8832/DECT firmware contains
+// the model name.
+func fingerprint(doc *goquery.Document) string {
+	return strings.TrimSpace(doc.Find("title").First().Text())
+}
+
+// selectScraper returns the Scraper to use for doc, along with its name for
+// logging. name is one of "auto", "cp8821", "cp8821ex", or "generic";
+// anything else is treated as "auto". In "auto" mode a page whose fingerprint
+// matches no registry entry falls back to "generic", for forward
+// compatibility with models this tool doesn't know about yet.
+func selectScraper(name string, doc *goquery.Document) (string, Scraper) {
+	switch name {
+	case "cp8821":
+		return "cp8821", cp8821Scraper{}
+	case "cp8821ex":
+		return "cp8821ex", cp8821exScraper{}
+	case "generic":
+		return "generic", genericScraper{}
+	}
+
+	fp := fingerprint(doc)
+	for _, entry := range registry {
+		if entry.matches(fp) {
+			return entry.name, entry.scraper
+		}
+	}
+	return "generic", genericScraper{}
+}
+
+// cp8821Scraper parses the battery table on the Cisco 8821 home page, as
+// shipped on the firmware this tool originally targeted: the third table on
+// the page, with "Battery health" / "Battery temperature:" rows.
+type cp8821Scraper struct{}
+
+func (cp8821Scraper) Parse(doc *goquery.Document) (BatteryInfo, error) {
+	return parseTableByIndex(doc, 2)
+}
+
+// cp8821exScraper parses the battery table on the Cisco 8821-EX home page.
+// The EX variant's firmware adds an extra intrinsic-safety summary table
+// ahead of the battery table, pushing it to the fourth table on the page.
+type cp8821exScraper struct{}
+
+func (cp8821exScraper) Parse(doc *goquery.Document) (BatteryInfo, error) {
+	return parseTableByIndex(doc, 3)
+}
+
+// parseTableByIndex locates the battery health & temperature rows inside the
+// table at the given zero-based index.
+func parseTableByIndex(doc *goquery.Document, tableIndex int) (BatteryInfo, error) {
+	var info BatteryInfo
+	doc.Find("table").Each(func(index int, tablehtml *goquery.Selection) {
+		if index != tableIndex {
+			return
+		}
+		tablehtml.Find("tr").Each(func(index int, tablerow *goquery.Selection) {
+			if strings.Contains(tablerow.Text(), "Battery health") {
+				info.Health = strings.Split(tablerow.Text(), "Battery health")[1]
+			}
+			if strings.Contains(tablerow.Text(), "Battery temperature:") {
+				info.Temp = strings.Split(tablerow.Text(), "Battery temperature: ")[1]
+			}
+		})
+	})
+	if info.Health == "" {
+		return info, fmt.Errorf("no battery health row found in table %d", tableIndex)
+	}
+	return info, nil
+}
+
+// healthLabels are the localized strings 8821/8821-EX/8832/DECT 6825 web UIs
+// use for the battery health label.
+var healthLabels = []string{"Battery health", "Akkuzustand", "État de la batterie", "电池运行状况"}
+
+// tempLabels are the localized strings used for the battery temperature
+// label.
+var tempLabels = []string{"Battery temperature", "Akkutemperatur", "Température de la batterie", "电池温度"}
+
+// genericScraper is the fallback used when no model-specific Scraper
+// matches: it walks every <tr> on the page looking for any localized
+// variant of the battery health/temperature labels, rather than relying on
+// a fixed table index.
+type genericScraper struct{}
+
+func (genericScraper) Parse(doc *goquery.Document) (BatteryInfo, error) {
+	var info BatteryInfo
+	doc.Find("tr").Each(func(_ int, row *goquery.Selection) {
+		text := row.Text()
+		for _, label := range healthLabels {
+			if value, ok := splitAfterLabel(text, label); ok {
+				info.Health = value
+			}
+		}
+		for _, label := range tempLabels {
+			if value, ok := splitAfterLabel(text, label); ok {
+				info.Temp = value
+			}
+		}
+	})
+	if info.Health == "" {
+		return info, fmt.Errorf("no battery health row found")
+	}
+	return info, nil
+}
+
+// splitAfterLabel returns the trimmed text following label within text, with
+// any leading ":" separator stripped.
+func splitAfterLabel(text, label string) (string, bool) {
+	if !strings.Contains(text, label) {
+		return "", false
+	}
+	value := strings.SplitN(text, label, 2)[1]
+	value = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(value), ":"))
+	return value, true
+}
@@ -0,0 +1,41 @@
+package source
+
+import (
+	"fmt"
+	"net"
+)
+
+// CIDRSource expands a CIDR block, e.g. 10.20.0.0/24, into every host address
+// it contains, so operators don't need to hand-enumerate large subnets.
+type CIDRSource struct {
+	CIDR string
+}
+
+// Addresses returns every host address in the block. The network and
+// broadcast addresses are skipped for blocks larger than a /31.
+func (c CIDRSource) Addresses() ([]string, error) {
+	ip, ipnet, err := net.ParseCIDR(c.CIDR)
+	if err != nil {
+		return nil, fmt.Errorf("parsing CIDR %q: %w", c.CIDR, err)
+	}
+
+	var addresses []string
+	for addr := ip.Mask(ipnet.Mask); ipnet.Contains(addr); incIP(addr) {
+		addresses = append(addresses, addr.String())
+	}
+	if len(addresses) > 2 {
+		// Drop the network & broadcast addresses.
+		addresses = addresses[1 : len(addresses)-1]
+	}
+	return addresses, nil
+}
+
+// incIP increments an IP address in place, treating it as a big-endian counter.
+func incIP(ip net.IP) {
+	for i := len(ip) - 1; i >= 0; i-- {
+		ip[i]++
+		if ip[i] != 0 {
+			break
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package source
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// CUCMSource pulls the list of registered Cisco 8821 devices from a Cisco
+// Unified Communications Manager cluster, so operators don't need to
+// hand-maintain an IP list for phones CUCM already knows about. It queries
+// AXL's listPhone for the device inventory (name/model), then RisPort for
+// each matching device's live registration IP - AXL's device config API
+// doesn't expose registration state or IP address, only RisPort does.
+type CUCMSource struct {
+	Host string
+	User string
+	Pass string
+
+	// Client overrides the HTTP client used to call AXL/RisPort. Defaults to
+	// a client that skips TLS verification if nil.
+	Client *http.Client
+}
+
+const axlListPhoneEnvelope = `<?xml version="1.0" encoding="utf-8"?>
+<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ns="http://www.cisco.com/AXL/API/12.0">
+  <soapenv:Body>
+    <ns:listPhone>
+      <searchCriteria>
+        <name>%</name>
+      </searchCriteria>
+      <returnedTags>
+        <name/>
+        <model/>
+      </returnedTags>
+    </ns:listPhone>
+  </soapenv:Body>
+</soapenv:Envelope>`
+
+type axlListPhoneResponse struct {
+	XMLName xml.Name `xml:"Envelope"`
+	Body    struct {
+		Fault             *soapFault `xml:"Fault"`
+		ListPhoneResponse struct {
+			Return struct {
+				Phone []struct {
+					Name  string `xml:"name"`
+					Model string `xml:"model"`
+				} `xml:"phone"`
+			} `xml:"return"`
+		} `xml:"listPhoneResponse"`
+	} `xml:"Body"`
+}
+
+// risSelectCmDeviceEnvelope asks RisPort for the real-time registration
+// status (including IP address) of the device names given in SelectItems.
+const risSelectCmDeviceEnvelope = `<?xml version="1.0" encoding="utf-8"?>
+<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/" xmlns:ns="http://schemas.cisco.com/ast/soap">
+  <soapenv:Body>
+    <ns:selectCmDeviceExt>
+      <StateInfo></StateInfo>
+      <CmSelectionCriteria>
+        <MaxReturnedDevices>1000</MaxReturnedDevices>
+        <DeviceClass>Phone</DeviceClass>
+        <Model>255</Model>
+        <Status>Registered</Status>
+        <NodeName></NodeName>
+        <SelectBy>Name</SelectBy>
+        <SelectItems>%s</SelectItems>
+        <Protocol>Any</Protocol>
+        <DownloadStatus>Any</DownloadStatus>
+      </CmSelectionCriteria>
+    </ns:selectCmDeviceExt>
+  </soapenv:Body>
+</soapenv:Envelope>`
+
+type risSelectCmDeviceResponse struct {
+	XMLName xml.Name `xml:"Envelope"`
+	Body    struct {
+		Fault                     *soapFault `xml:"Fault"`
+		SelectCmDeviceExtResponse struct {
+			// RisPort70 wraps its payload in selectCmDeviceReturn, not the
+			// bare "return" AXL uses.
+			Return struct {
+				TotalDevicesFound int `xml:"TotalDevicesFound"`
+				CmNodes           struct {
+					Item []struct {
+						CmDevices struct {
+							Item []struct {
+								Name      string `xml:"Name"`
+								Status    string `xml:"Status"`
+								IPAddress struct {
+									Item []struct {
+										IP string `xml:"IP"`
+									} `xml:"item"`
+								} `xml:"IPAddress"`
+							} `xml:"item"`
+						} `xml:"CmDevices"`
+					} `xml:"item"`
+				} `xml:"CmNodes"`
+			} `xml:"selectCmDeviceReturn"`
+		} `xml:"selectCmDeviceExtResponse"`
+	} `xml:"Body"`
+}
+
+// soapFault is the standard SOAP 1.1 fault body CUCM returns for AXL/RisPort
+// errors (bad credentials, invalid returnedTags, etc).
+type soapFault struct {
+	FaultString string `xml:"faultstring"`
+}
+
+// Addresses calls AXL's listPhone to find every registered device whose
+// model is an 8821, then RisPort to resolve each one's live registration IP.
+// Filtering on model happens client-side since listPhone's returnedTags don't
+// support filtering by model.
+func (c CUCMSource) Addresses() ([]string, error) {
+	client := c.Client
+	if client == nil {
+		// CUCM clusters overwhelmingly serve AXL/RisPort on a self-signed or
+		// internal-CA cert, same as the phones themselves.
+		client = &http.Client{
+			Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}},
+		}
+	}
+
+	var parsed axlListPhoneResponse
+	if err := c.soapCall(client,
+		fmt.Sprintf("https://%s:8443/axl/", c.Host),
+		axlListPhoneEnvelope,
+		"CUCM:DB ver=12.0 listPhone",
+		&parsed); err != nil {
+		return nil, fmt.Errorf("querying CUCM AXL for device inventory: %w", err)
+	}
+	if parsed.Body.Fault != nil {
+		return nil, fmt.Errorf("CUCM AXL listPhone fault: %s", parsed.Body.Fault.FaultString)
+	}
+
+	var names []string
+	for _, phone := range parsed.Body.ListPhoneResponse.Return.Phone {
+		if strings.Contains(phone.Model, "8821") {
+			names = append(names, phone.Name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	var items strings.Builder
+	for _, name := range names {
+		items.WriteString("<item><Name>" + name + "</Name></item>")
+	}
+
+	var risResp risSelectCmDeviceResponse
+	if err := c.soapCall(client,
+		fmt.Sprintf("https://%s:8443/realtimeservice2/services/RISService70", c.Host),
+		fmt.Sprintf(risSelectCmDeviceEnvelope, items.String()),
+		"http://schemas.cisco.com/ast/soap/action/#SelectCmDeviceExt",
+		&risResp); err != nil {
+		return nil, fmt.Errorf("querying CUCM RisPort for registration status: %w", err)
+	}
+	if risResp.Body.Fault != nil {
+		return nil, fmt.Errorf("CUCM RisPort selectCmDeviceExt fault: %s", risResp.Body.Fault.FaultString)
+	}
+
+	var addresses []string
+	for _, node := range risResp.Body.SelectCmDeviceExtResponse.Return.CmNodes.Item {
+		for _, device := range node.CmDevices.Item {
+			if device.Status != "Registered" || len(device.IPAddress.Item) == 0 {
+				continue
+			}
+			addresses = append(addresses, device.IPAddress.Item[0].IP)
+		}
+	}
+	return addresses, nil
+}
+
+// soapCall POSTs envelope to url with soapAction and decodes the response
+// body into out. A non-200 response is surfaced as an error, including the
+// body, since CUCM returns auth failures, 404s, and SOAP faults that way
+// rather than always with a parseable fault document.
+func (c CUCMSource) soapCall(client *http.Client, url, envelope, soapAction string, out interface{}) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBufferString(envelope))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.User, c.Pass)
+	req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+	req.Header.Set("SOAPAction", soapAction)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	if err := xml.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decoding response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package source
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestCUCMSourceAddresses proves a real AXL listPhone + RisPort
+// selectCmDeviceExt exchange parses end to end: the 8821 registers and is
+// returned, the non-8821 model is filtered out, and the unregistered 8821
+// is skipped since RisPort has no IP for it.
+func TestCUCMSourceAddresses(t *testing.T) {
+	const axlResponse = `<?xml version="1.0" encoding="utf-8"?>
+<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/">
+  <soapenv:Body>
+    <ns:listPhoneResponse xmlns:ns="http://www.cisco.com/AXL/API/12.0">
+      <return>
+        <phone><name>SEPAAAAAAAAAAAA</name><model>Cisco 8821</model></phone>
+        <phone><name>SEPBBBBBBBBBBBB</name><model>Cisco 8865</model></phone>
+        <phone><name>SEPCCCCCCCCCCCC</name><model>Cisco 8821</model></phone>
+      </return>
+    </ns:listPhoneResponse>
+  </soapenv:Body>
+</soapenv:Envelope>`
+
+	// Shape taken from a real RisPort70 selectCmDeviceExt response: the
+	// payload is wrapped in selectCmDeviceReturn (not "return", which is
+	// AXL's naming), and each device carries several fields beyond the ones
+	// CUCMSource reads.
+	const risResponse = `<?xml version="1.0" encoding="utf-8"?>
+<soapenv:Envelope xmlns:soapenv="http://schemas.xmlsoap.org/soap/envelope/">
+  <soapenv:Body>
+    <ns:selectCmDeviceExtResponse xmlns:ns="http://schemas.cisco.com/ast/soap">
+      <selectCmDeviceReturn>
+        <TotalDevicesFound>2</TotalDevicesFound>
+        <CmNodes>
+          <item>
+            <Name>cucm-node1</Name>
+            <Status>2</Status>
+            <CmDevices>
+              <item>
+                <Name>SEPAAAAAAAAAAAA</Name>
+                <DirNum>1001</DirNum>
+                <Class>Phone</Class>
+                <Model>495</Model>
+                <Product>Cisco 8821</Product>
+                <Status>Registered</Status>
+                <StatusReason>0</StatusReason>
+                <IPAddress>
+                  <item>
+                    <IP>10.0.0.21</IP>
+                    <IPAddrType>ipv4</IPAddrType>
+                    <Active>true</Active>
+                  </item>
+                </IPAddress>
+                <Timestamp>1753300000</Timestamp>
+                <Timezone>78</Timezone>
+                <Duplex>Unknown</Duplex>
+                <Description>Wireless Phone</Description>
+              </item>
+              <item>
+                <Name>SEPCCCCCCCCCCCC</Name>
+                <DirNum>1002</DirNum>
+                <Class>Phone</Class>
+                <Model>495</Model>
+                <Product>Cisco 8821</Product>
+                <Status>Unregistered</Status>
+                <StatusReason>1</StatusReason>
+                <IPAddress></IPAddress>
+                <Timestamp>1753300000</Timestamp>
+                <Timezone>78</Timezone>
+                <Duplex>Unknown</Duplex>
+                <Description>Wireless Phone</Description>
+              </item>
+            </CmDevices>
+          </item>
+        </CmNodes>
+      </selectCmDeviceReturn>
+    </ns:selectCmDeviceExtResponse>
+  </soapenv:Body>
+</soapenv:Envelope>`
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/axl/", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, axlResponse)
+	})
+	mux.HandleFunc("/realtimeservice2/services/RISService70", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, risResponse)
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	src := CUCMSource{
+		Host:   strings.TrimPrefix(srv.URL, "http://"),
+		User:   "axluser",
+		Pass:   "axlpass",
+		Client: srv.Client(),
+	}
+	// Both calls go to the same test server, but the code always requests
+	// https://host:8443/..., so point it at the httptest server instead by
+	// overriding the scheme via a RoundTripper.
+	src.Client = &http.Client{Transport: rewriteSchemeTransport{base: srv.URL}}
+
+	addresses, err := src.Addresses()
+	if err != nil {
+		t.Fatalf("Addresses() returned error: %v", err)
+	}
+	if len(addresses) != 1 || addresses[0] != "10.0.0.21" {
+		t.Fatalf("Addresses() = %v, want [10.0.0.21]", addresses)
+	}
+}
+
+// rewriteSchemeTransport redirects every request to base, regardless of the
+// scheme/host/port the caller built the request with, so tests can point
+// CUCMSource (which always calls https://host:8443/...) at an httptest
+// server.
+type rewriteSchemeTransport struct {
+	base string
+}
+
+func (t rewriteSchemeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	target := t.base + req.URL.Path
+	newReq, err := http.NewRequest(req.Method, target, req.Body)
+	if err != nil {
+		return nil, err
+	}
+	newReq.Header = req.Header
+	return http.DefaultTransport.RoundTrip(newReq)
+}
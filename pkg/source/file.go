@@ -0,0 +1,51 @@
+package source
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// FileSource reads a plain text file containing one address per line - the
+// tool's original input format.
+type FileSource struct {
+	Path    string
+	Verbose bool
+}
+
+// Addresses reads Path and returns every line that parses as a valid IP
+// address (an optional ":port" suffix is allowed). Invalid lines are skipped
+// and reported rather than failing the whole source.
+func (f FileSource) Addresses() ([]string, error) {
+	infile, err := os.Open(f.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer infile.Close()
+
+	var addresses []string
+	invalid := 0
+	scanner := bufio.NewScanner(infile)
+	for scanner.Scan() {
+		// Strip any whitespace from IP
+		ip := strings.TrimSpace(scanner.Text())
+		if ip == "" {
+			continue
+		}
+		// Ensure IP is valid
+		if net.ParseIP(strings.Split(ip, ":")[0]) == nil {
+			invalid++
+			if f.Verbose {
+				fmt.Println("Invalid address: ", ip)
+			}
+			continue
+		}
+		addresses = append(addresses, ip)
+	}
+	if invalid > 0 {
+		fmt.Printf("%d addresses are invalid & will not be checked.\n", invalid)
+	}
+	return addresses, scanner.Err()
+}
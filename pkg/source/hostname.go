@@ -0,0 +1,90 @@
+package source
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// HostnameSource resolves a fixed list of DNS hostnames on every scan. Lookups
+// are cached for TTL, including failures (e.g. NXDOMAIN), so one broken entry
+// doesn't get re-resolved on every scan.
+type HostnameSource struct {
+	Hostnames []string
+	TTL       time.Duration
+	Verbose   bool
+
+	once     sync.Once
+	resolver *cachedResolver
+}
+
+// Addresses resolves every hostname and returns the ones that succeeded.
+// Hostnames that fail to resolve are logged in verbose mode and skipped.
+func (h *HostnameSource) Addresses() ([]string, error) {
+	h.once.Do(func() {
+		h.resolver = newCachedResolver(h.TTL)
+	})
+
+	var addresses []string
+	for _, host := range h.Hostnames {
+		ip, err := h.resolver.resolve(host)
+		if err != nil {
+			if h.Verbose {
+				fmt.Println("Could not resolve " + host + ": " + err.Error())
+			}
+			continue
+		}
+		addresses = append(addresses, ip)
+	}
+	return addresses, nil
+}
+
+// cacheEntry remembers the outcome of resolving a single hostname, success or
+// failure, until it expires.
+type cacheEntry struct {
+	ip      string
+	err     error
+	expires time.Time
+}
+
+// cachedResolver resolves hostnames to their first IP address, caching both
+// successes and failures for ttl.
+type cachedResolver struct {
+	ttl time.Duration
+
+	mu    sync.Mutex
+	cache map[string]cacheEntry
+}
+
+func newCachedResolver(ttl time.Duration) *cachedResolver {
+	return &cachedResolver{ttl: ttl, cache: make(map[string]cacheEntry)}
+}
+
+func (r *cachedResolver) resolve(host string) (string, error) {
+	r.mu.Lock()
+	if entry, ok := r.cache[host]; ok && time.Now().Before(entry.expires) {
+		r.mu.Unlock()
+		return entry.ip, entry.err
+	}
+	r.mu.Unlock()
+
+	ips, err := net.LookupIP(host)
+	var ip string
+	switch {
+	case err != nil:
+		// Keep err as-is (e.g. the *net.DNSError for NXDOMAIN) so it gets
+		// cached and reported verbatim.
+	case len(ips) == 0:
+		err = errors.New("no addresses returned")
+	default:
+		ip = ips[0].String()
+	}
+
+	r.mu.Lock()
+	r.cache[host] = cacheEntry{ip: ip, err: err, expires: time.Now().Add(r.ttl)}
+	r.mu.Unlock()
+
+	return ip, err
+}
@@ -0,0 +1,25 @@
+/*
+Copyright (c) 2022 Cisco and/or its affiliates.
+This software is licensed to you under the terms of the Cisco Sample
+Code License, Version 1.1 (the "License"). You may obtain a copy of the
+License at
+               https://developer.cisco.com/docs/licenses
+All use of the material herein must be in accordance with the terms of
+the License. All rights not expressly granted by the License are
+reserved. Unless required by applicable law or agreed to separately in
+writing, software distributed under the License is distributed on an "AS
+IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express
+or implied.
+*/
+
+// Package source builds the list of addresses that a scan should check.
+// Whatever the source - a static file, a CIDR block, a list of hostnames, or
+// a CUCM cluster - the result is just a slice of addresses that flows into
+// the same pkg/scan worker pool.
+package source
+
+// Source produces the list of addresses (IPs or hostnames, optionally with a
+// ":port" suffix) that a scan should check.
+type Source interface {
+	Addresses() ([]string, error)
+}